@@ -2,6 +2,7 @@ package native
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"sync/atomic"
 
@@ -10,25 +11,113 @@ import (
 	"github.com/ethereum/go-ethereum/eth/tracers"
 )
 
+// errorSelector and panicSelector are the 4-byte selectors of the two
+// builtin revert encodings Solidity emits: Error(string) for require/revert
+// with a message, and Panic(uint256) for compiler-inserted checks (div by
+// zero, assert, out-of-bounds, ...).
+var (
+	errorSelector = [4]byte{0x08, 0xc3, 0x79, 0xa0}
+	panicSelector = [4]byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// decodeRevertReason best-effort decodes the revert reason out of a reverted
+// call's return data. It understands the two standard Solidity encodings and
+// returns "" for anything else (e.g. a custom error, or no return data at
+// all).
+func decodeRevertReason(output []byte) string {
+	if len(output) < 4 {
+		return ""
+	}
+	var selector [4]byte
+	copy(selector[:], output[:4])
+	data := output[4:]
+
+	switch selector {
+	case errorSelector:
+		// Error(string): offset(32) | length(32) | bytes
+		if len(data) < 64 {
+			return ""
+		}
+		// data[32:64] is attacker-controlled: a contract can revert with an
+		// arbitrary 32-byte word here, so strLen must be range-checked
+		// against the actual remaining data before it's used as a slice
+		// bound - otherwise 64+strLen silently wraps around uint64 and the
+		// slice below panics.
+		lenWord := new(big.Int).SetBytes(data[32:64])
+		if !lenWord.IsUint64() {
+			return ""
+		}
+		strLen := lenWord.Uint64()
+		if strLen > uint64(len(data)-64) {
+			return ""
+		}
+		return string(data[64 : 64+strLen])
+	case panicSelector:
+		// Panic(uint256): a single 32-byte panic code
+		if len(data) < 32 {
+			return ""
+		}
+		code := new(big.Int).SetBytes(data[:32])
+		return fmt.Sprintf("panic: 0x%x", code)
+	default:
+		return ""
+	}
+}
+
 func init() {
 	tracers.DefaultDirectory.Register("fastCallTracer", newFastCallTracer, false)
 }
 
+// fastCallTracerConfig are the configuration options available to the
+// fastCallTracer, set through the tracer's JSON config.
+type fastCallTracerConfig struct {
+	WithLogs     bool `json:"withLogs"`     // accepted for callTracer config compatibility; fastCallTracer never records logs
+	WithSubcalls bool `json:"withSubcalls"` // collect a flat list of subcalls instead of discarding CaptureEnter/CaptureExit
+}
+
+// subcallFrame is the minimal record kept for a subcall when withSubcalls is
+// enabled: just enough to see which calls happened and whether they
+// reverted, without the input/output byte copies a full callTracer frame
+// carries.
+type subcallFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *big.Int       `json:"value,omitempty"`
+	GasUsed uint64         `json:"gasUsed"`
+	Error   string         `json:"error,omitempty"`
+}
+
 type simplifiedCallFrame struct {
-	Output []byte `json:"output,omitempty"` // Only store the output
+	Output       []byte         `json:"output,omitempty"`       // Return data of the top-level call
+	GasUsed      uint64         `json:"gasUsed"`                // Total gas used by the top-level call
+	Error        string         `json:"error,omitempty"`        // EVM error, e.g. "execution reverted"
+	RevertReason string         `json:"revertReason,omitempty"` // Decoded Error(string)/Panic(uint256) reason, if any
+	Calls        []subcallFrame `json:"calls,omitempty"`        // Only populated when withSubcalls is set
 }
 
 type fastCallTracer struct {
 	noopTracer
+	config    fastCallTracerConfig
 	output    []byte
+	gasUsed   uint64
+	errorVal  string
+	revert    string
+	calls     []subcallFrame
+	callStack []int // indexes into calls, one per currently open CaptureEnter, for matching CaptureExit to its frame
 	gasLimit  uint64
 	interrupt atomic.Bool // Atomic flag to signal execution interruption
 	reason    error       // Textual reason for the interruption
 }
 
 func newFastCallTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
-	// Simplified tracer does not use configuration
-	return &fastCallTracer{}, nil
+	var config fastCallTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &fastCallTracer{config: config}, nil
 }
 
 func (t *fastCallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
@@ -40,6 +129,11 @@ func (t *fastCallTracer) CaptureStart(env *vm.EVM, from common.Address, to commo
 func (t *fastCallTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
 	// Directly store the output at the end of the top-level call
 	t.output = common.CopyBytes(output)
+	t.gasUsed = gasUsed
+	if err != nil {
+		t.errorVal = err.Error()
+		t.revert = decodeRevertReason(output)
+	}
 }
 
 // Override the CaptureState method to do nothing for efficiency
@@ -47,18 +141,52 @@ func (t *fastCallTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64,
 	// No operation, since internal state changes are not of interest
 }
 
-// CaptureEnter and CaptureExit are overridden to do nothing since internal calls are not tracked
+// CaptureEnter records a subcall frame when withSubcalls is enabled and
+// pushes its index onto callStack so the matching CaptureExit - which isn't
+// necessarily the next one, since calls nest - can find it again; otherwise
+// it's a no-op, matching the "fast" spirit of skipping anything that isn't
+// needed to tell whether and where a call reverted.
 func (t *fastCallTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
-	// No operation, since internal calls are not tracked
+	if !t.config.WithSubcalls {
+		return
+	}
+	t.callStack = append(t.callStack, len(t.calls))
+	t.calls = append(t.calls, subcallFrame{
+		Type:  typ.String(),
+		From:  from,
+		To:    to,
+		Value: value,
+	})
 }
 
+// CaptureExit pops the index CaptureEnter pushed and fills in the gas used
+// and error, if any, of that frame specifically - not just the most
+// recently appended one, which for nested calls (A enters, B enters, B
+// exits, A exits) would wrongly attribute A's exit to B's frame. It's a
+// no-op when withSubcalls is disabled.
 func (t *fastCallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
-	// No operation, since internal calls are not tracked
+	if !t.config.WithSubcalls || len(t.callStack) == 0 {
+		return
+	}
+	last := len(t.callStack) - 1
+	idx := t.callStack[last]
+	t.callStack = t.callStack[:last]
+
+	t.calls[idx].GasUsed = gasUsed
+	if err != nil {
+		t.calls[idx].Error = err.Error()
+	}
 }
 
 func (t *fastCallTracer) GetResult() (json.RawMessage, error) {
 	// Return the output directly without any additional processing
-	res, err := json.Marshal(simplifiedCallFrame{Output: t.output})
+	res, err := json.Marshal(simplifiedCallFrame{
+		Output:       t.output,
+		GasUsed:      t.gasUsed,
+		Error:        t.errorVal,
+		RevertReason: t.revert,
+		Calls:        t.calls,
+	})
 	if err != nil {
 		return nil, err
 	}
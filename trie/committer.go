@@ -18,6 +18,7 @@ package trie
 
 import (
 	"fmt"
+	"runtime"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -25,6 +26,13 @@ import (
 	"github.com/ethereum/go-ethereum/trie/triestate"
 )
 
+// parallelCommitDepth bounds how close to the root a fullNode's children are
+// fanned out to the worker pool. The average subtree shrinks fast with
+// depth, so below this many hex nibbles from the root the goroutine and
+// staging-nodeset overhead of the parallel path outweighs what it saves and
+// commitChildren falls back to committing serially.
+const parallelCommitDepth = 2
+
 // leafChanSize is the size of the leafCh. It's a pretty arbitrary number, to allow
 // some parallelism but not incur too much memory overhead.
 const leafChanSize = 200
@@ -44,10 +52,14 @@ type leafInfo struct {
 // capture all dirty nodes during the commit process and keep them cached in
 // insertion order.
 type committer struct {
-	nodes  *trienode.NodeSet
-	tracer *tracer
-	onleaf triestate.LeafCallback
-	leafCh chan *leafInfo
+	nodes    *trienode.NodeSet
+	tracer   *tracer
+	onleaf   triestate.LeafCallback
+	leafCh   chan *leafInfo
+	parallel bool        // whether fullNode children above parallelCommitDepth may be committed concurrently
+	nodesMu  sync.Mutex  // guards nodes, needed once commitLoop workers or CommitWithLeafCallback run alongside commit
+	sink     ProofSink   // optional, receives every node as it's stored
+	sinkMu   *sync.Mutex // non-nil on a sub-committer spawned by commitChildrenParallel; guards concurrent sink.Put calls from sibling workers sharing the same sink
 }
 
 // committers live in a global sync.Pool
@@ -57,11 +69,19 @@ var committerPool = sync.Pool{
 	},
 }
 
-// newCommitter creates a new committer or picks one from the pool.
-func newCommitter(nodeset *trienode.NodeSet, tracer *tracer) *committer {
+// newCommitter creates a new committer or picks one from the pool. When
+// parallel is set, commitChildren may dispatch a fullNode's children to a
+// bounded worker pool instead of walking them one at a time; callers that
+// commit many large tries back-to-back (state commit, snap sync) can opt
+// into this to cut down on wall-clock time at the cost of some extra
+// goroutines and temporary nodesets. sink, if non-nil, is notified of every
+// node the committer stores - see ProofSink.
+func newCommitter(nodeset *trienode.NodeSet, tracer *tracer, parallel bool, sink ProofSink) *committer {
 	committer := committerPool.Get().(*committer)
 	committer.nodes = nodeset
 	committer.tracer = tracer
+	committer.parallel = parallel
+	committer.sink = sink
 
 	return committer
 }
@@ -71,6 +91,9 @@ func returnCommitterToPool(h *committer) {
 	h.tracer = nil
 	h.onleaf = nil
 	h.leafCh = nil
+	h.parallel = false
+	h.sink = nil
+	h.sinkMu = nil
 	committerPool.Put(h)
 }
 
@@ -79,6 +102,20 @@ func (c *committer) Commit(n node) hashNode {
 	return c.commit(nil, n).(hashNode)
 }
 
+// CommitParallel force-commits root with the parallel fan-out enabled and
+// returns the resulting hash node, independent of any Trie's own
+// parallelCommit setting. Trie.Commit doesn't call this directly - it opens
+// a committer itself via newCommitter(..., t.parallelCommit, ...) so a trie
+// that wasn't configured for parallel commits still goes through the serial
+// path - but CommitParallel is the same call shape, and is what
+// BenchmarkCommitParallel1M uses to benchmark the parallel path without
+// needing a populated Trie.
+func CommitParallel(root node, nodes *trienode.NodeSet, tracer *tracer) hashNode {
+	h := newCommitter(nodes, tracer, true, nil)
+	defer returnCommitterToPool(h)
+	return h.Commit(root)
+}
+
 // commit collapses a node down into a hash node and returns it.
 func (c *committer) commit(path []byte, n node) node {
 	// if this path is clean, use available cached data
@@ -123,8 +160,20 @@ func (c *committer) commit(path []byte, n node) node {
 	}
 }
 
-// commitChildren commits the children of the given fullnode
+// commitChildren commits the children of the given fullnode. Close to the
+// root, where subtrees are large, it fans the 16 branch children out to a
+// bounded worker pool; deeper down it falls back to the serial path where
+// the per-child subtree is too small for that to pay off.
 func (c *committer) commitChildren(path []byte, n *fullNode) [17]node {
+	if c.parallel && len(path) <= parallelCommitDepth {
+		return c.commitChildrenParallel(path, n)
+	}
+	return c.commitChildrenSerial(path, n)
+}
+
+// commitChildrenSerial commits the children of the given fullnode one at a
+// time, in index order.
+func (c *committer) commitChildrenSerial(path []byte, n *fullNode) [17]node {
 	var children [17]node
 	for i := 0; i < 16; i++ {
 		child := n.Children[i]
@@ -150,6 +199,83 @@ func (c *committer) commitChildren(path []byte, n *fullNode) [17]node {
 	return children
 }
 
+// commitChildrenParallel commits the non-hashed children of the given
+// fullnode across a worker pool bounded by GOMAXPROCS. Each worker commits
+// its child into its own staging trienode.NodeSet - so it never touches
+// c.nodes until the fan-out is done - and owns its own committer (and thus
+// its own hasher state) from the pool, just like a fresh top-level commit
+// would. The staging sets are merged into c.nodes in child-index order once
+// every worker has finished, so the insertion order callers observe for a
+// given path is identical to the serial path.
+//
+// Workers share the parent's leafCh (sends to a channel are already safe
+// for concurrent senders, so every leaf anywhere in the fan-out still goes
+// through the same commitLoop pool and onleaf callback started by
+// CommitWithLeafCallback) and, if a sink is set, a single sinkMu that
+// serializes their sink.Put calls - ProofSink implementations like
+// WitnessBuilder are not expected to be safe for concurrent use on their
+// own.
+//
+// The per-child committers are not parallel themselves, so the fan-out
+// happens at most once along any path: everything below a fanned-out
+// fullNode is committed serially inside its worker, whatever its depth.
+func (c *committer) commitChildrenParallel(path []byte, n *fullNode) [17]node {
+	var (
+		children [17]node
+		staged   [16]*trienode.NodeSet
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, runtime.GOMAXPROCS(0))
+	)
+	var sinkMu *sync.Mutex
+	if c.sink != nil {
+		sinkMu = new(sync.Mutex)
+	}
+	for i := 0; i < 16; i++ {
+		child := n.Children[i]
+		if child == nil {
+			continue
+		}
+		if hn, ok := child.(hashNode); ok {
+			children[i] = hn
+			continue
+		}
+		i, child := i, child
+		childPath := append(append([]byte{}, path...), byte(i))
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			sub := newCommitter(trienode.NewNodeSet(c.nodes.Owner), c.tracer, false, c.sink)
+			sub.sinkMu = sinkMu
+			sub.leafCh = c.leafCh
+			sub.onleaf = c.onleaf
+			children[i] = sub.commit(childPath, child)
+			staged[i] = sub.nodes
+			returnCommitterToPool(sub)
+		}()
+	}
+	wg.Wait()
+
+	// commitLoop workers (started by CommitWithLeafCallback) mutate c.nodes
+	// via AddLeaf under nodesMu concurrently with this merge whenever a
+	// leafCh was propagated above, so the merge itself must take the same
+	// lock.
+	c.nodesMu.Lock()
+	for i := 0; i < 16; i++ {
+		if staged[i] != nil {
+			c.nodes.MergeSet(staged[i])
+		}
+	}
+	c.nodesMu.Unlock()
+	if n.Children[16] != nil {
+		children[16] = n.Children[16]
+	}
+	return children
+}
+
 // store hashes the node n and adds it to the modified nodeset. If leaf collection
 // is enabled, leaf nodes will be tracked in the modified nodeset as well.
 func (c *committer) store(path []byte, n node) node {
@@ -166,13 +292,28 @@ func (c *committer) store(path []byte, n node) node {
 		// deleted only if the node was existent in database before.
 		_, ok := c.tracer.accessList[string(path)]
 		if ok {
+			c.nodesMu.Lock()
 			c.nodes.AddNode(path, trienode.NewDeleted())
+			c.nodesMu.Unlock()
 		}
 		return n
 	}
 	// Collect the dirty node to nodeset for return.
 	nhash := common.BytesToHash(hash)
-	c.nodes.AddNode(path, trienode.New(nhash, nodeToBytes(n)))
+	enc := nodeToBytes(n)
+	c.nodesMu.Lock()
+	c.nodes.AddNode(path, trienode.New(nhash, enc))
+	c.nodesMu.Unlock()
+
+	if c.sink != nil {
+		if c.sinkMu != nil {
+			c.sinkMu.Lock()
+			c.sink.Put(path, nhash, enc)
+			c.sinkMu.Unlock()
+		} else {
+			c.sink.Put(path, nhash, enc)
+		}
+	}
 
 	// Collect the corresponding leaf node if it's required. We don't check
 	// full node since it's impossible to store value in fullNode. The key
@@ -185,14 +326,21 @@ func (c *committer) store(path []byte, n node) node {
 	} else {
 		if sn, ok := n.(*shortNode); ok {
 			if val, ok := sn.Val.(valueNode); ok {
+				c.nodesMu.Lock()
 				c.nodes.AddLeaf(nhash, val)
+				c.nodesMu.Unlock()
 			}
 		}
 	}
 	return hash
 }
 
-// commitLoop does the actual insert + leaf callback for nodes.
+// commitLoop does the actual insert + leaf callback for nodes. It's safe to
+// run any number of these concurrently against the same committer: all
+// c.nodes access is serialized through c.nodesMu, and c.onleaf is expected
+// to tolerate concurrent calls (the same contract upstream go-ethereum's
+// state committer relies on to build account-storage-root maps while the
+// account trie is still being hashed).
 func (c *committer) commitLoop() {
 	for item := range c.leafCh {
 		var (
@@ -204,21 +352,61 @@ func (c *committer) commitLoop() {
 			switch n := n.(type) {
 			case *shortNode:
 				if child, ok := n.Val.(valueNode); ok {
+					c.nodesMu.Lock()
 					c.nodes.AddLeaf(parent, child)
+					c.nodesMu.Unlock()
 					c.onleaf(nil, nil, child, parent, nil)
 				}
 			case *fullNode:
 				// For children in range [0, 15], it's impossible
 				// to contain valueNode. Only check the 17th child.
 				if n.Children[16] != nil {
-					c.nodes.AddLeaf(parent, n.Children[16].(valueNode))
-					c.onleaf(nil, nil, n.Children[16].(valueNode), parent, nil)
+					child := n.Children[16].(valueNode)
+					c.nodesMu.Lock()
+					c.nodes.AddLeaf(parent, child)
+					c.nodesMu.Unlock()
+					c.onleaf(nil, nil, child, parent, nil)
 				}
 			}
 		}
 	}
 }
 
+// CommitWithLeafCallback is like Commit, but streams every committed leaf
+// through cb via a pool of workers running commitLoop, instead of
+// leaving leaf collection to the synchronous AddLeaf branch of store. This
+// lets a caller such as core/state populate its account-storage-root map
+// concurrently with hashing the account trie, and lets plugins like
+// snap-sync healing observe every committed leaf without a second traversal
+// of the trie - the same pattern upstream go-ethereum's state committer
+// uses for its own onleaf callback.
+func (c *committer) CommitWithLeafCallback(n node, cb triestate.LeafCallback, workers int) (hashNode, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	c.onleaf = cb
+	c.leafCh = make(chan *leafInfo, leafChanSize)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			c.commitLoop()
+		}()
+	}
+
+	hashed := c.commit(nil, n)
+	close(c.leafCh)
+	wg.Wait()
+
+	hn, ok := hashed.(hashNode)
+	if !ok {
+		return nil, fmt.Errorf("commit: root collapsed to non-hash node %T", hashed)
+	}
+	return hn, nil
+}
+
 // mptResolver the children resolver in merkle-patricia-tree.
 type mptResolver struct{}
 
@@ -0,0 +1,214 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"sync"
+)
+
+// nodeEncoderPool recycles the scratch buffers used by encodeNode. On a full
+// state commit nearly every dirty node goes through here, so reusing a
+// buffer instead of growing a fresh one with rlp.Encode (which walks the
+// node via reflection) cuts commit-path allocations substantially.
+var nodeEncoderPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 128)
+		return &b
+	},
+}
+
+// encodeNode returns the RLP wire encoding of n, written directly into a
+// pooled buffer sized ahead of time with estimateSize rather than produced
+// by the reflection-based rlp.Encode. The returned slice is only valid until
+// release is called; callers that need to retain the bytes must copy them
+// first (see nodeToBytes).
+func encodeNode(n node) (enc []byte, release func()) {
+	bufp := nodeEncoderPool.Get().(*[]byte)
+	buf := *bufp
+	if want := estimateSize(n); cap(buf) < want {
+		buf = make([]byte, 0, want)
+	}
+	buf = buf[:0]
+
+	switch n := n.(type) {
+	case *shortNode:
+		buf = encodeShort(buf, n)
+	case *fullNode:
+		buf = encodeFull(buf, n)
+	case hashNode:
+		buf = appendRLPBytes(buf, n)
+	case valueNode:
+		buf = appendRLPBytes(buf, n)
+	default:
+		panic(fmt.Sprintf("encodeNode: unsupported node type %T", n))
+	}
+
+	*bufp = buf
+	return buf, func() { nodeEncoderPool.Put(bufp) }
+}
+
+// nodeToBytes returns the RLP encoding of n as an independent, owned byte
+// slice. It's a thin allocating wrapper around encodeNode for callers - such
+// as committer.store, which hands the bytes to trienode.New - that need to
+// retain the encoding past the call.
+func nodeToBytes(n node) []byte {
+	enc, release := encodeNode(n)
+	out := make([]byte, len(enc))
+	copy(out, enc)
+	release()
+	return out
+}
+
+// encodeShort appends the RLP encoding of a shortNode - a 2-item list of its
+// compact-encoded key and its (already hash-collapsed) value - to buf.
+func encodeShort(buf []byte, n *shortNode) []byte {
+	payload := rlpStringLen(n.Key) + childEncodedLen(n.Val)
+	buf = appendListHeader(buf, payload)
+	buf = appendRLPBytes(buf, n.Key)
+	return appendChild(buf, n.Val)
+}
+
+// encodeFull appends the RLP encoding of a fullNode - a 17-item list of its
+// children, each either a 32-byte hash, an embedded value, or the empty
+// string for an absent slot - to buf.
+func encodeFull(buf []byte, n *fullNode) []byte {
+	payload := 0
+	for _, child := range n.Children {
+		payload += childEncodedLen(child)
+	}
+	buf = appendListHeader(buf, payload)
+	for _, child := range n.Children {
+		buf = appendChild(buf, child)
+	}
+	return buf
+}
+
+// appendChild appends the RLP encoding of a single fullNode/shortNode child.
+// A child too small to be stored under its own hash (<32 bytes encoded) is
+// embedded directly in its parent as a *shortNode or *fullNode rather than
+// collapsed to a hashNode, so the encoder has to recurse into it exactly as
+// rlp.Encode would via the node's EncodeRLP method.
+func appendChild(buf []byte, n node) []byte {
+	switch n := n.(type) {
+	case nil:
+		return append(buf, 0x80)
+	case valueNode:
+		return appendRLPBytes(buf, n)
+	case hashNode:
+		return appendRLPBytes(buf, n)
+	case *shortNode:
+		return encodeShort(buf, n)
+	case *fullNode:
+		return encodeFull(buf, n)
+	default:
+		panic(fmt.Sprintf("appendChild: unexpected child node %T", n))
+	}
+}
+
+// childEncodedLen returns the number of bytes appendChild would write for n,
+// without writing them.
+func childEncodedLen(n node) int {
+	switch n := n.(type) {
+	case nil:
+		return 1
+	case valueNode:
+		return rlpStringLen(n)
+	case hashNode:
+		return rlpStringLen(n)
+	case *shortNode:
+		return listEncodedLen(rlpStringLen(n.Key) + childEncodedLen(n.Val))
+	case *fullNode:
+		payload := 0
+		for _, child := range n.Children {
+			payload += childEncodedLen(child)
+		}
+		return listEncodedLen(payload)
+	default:
+		panic(fmt.Sprintf("childEncodedLen: unexpected child node %T", n))
+	}
+}
+
+// rlpStringLen returns the number of bytes the RLP encoding of b occupies,
+// including its header.
+func rlpStringLen(b []byte) int {
+	switch {
+	case len(b) == 1 && b[0] < 0x80:
+		return 1
+	case len(b) < 56:
+		return 1 + len(b)
+	default:
+		return 1 + intsize(len(b)) + len(b)
+	}
+}
+
+// appendRLPBytes appends the RLP string encoding of b to buf.
+func appendRLPBytes(buf, b []byte) []byte {
+	switch {
+	case len(b) == 1 && b[0] < 0x80:
+		return append(buf, b[0])
+	case len(b) < 56:
+		buf = append(buf, 0x80+byte(len(b)))
+		return append(buf, b...)
+	default:
+		buf = appendLongHeader(buf, 0xb7, len(b))
+		return append(buf, b...)
+	}
+}
+
+// appendListHeader appends the RLP list header for a list whose payload is
+// payload bytes long.
+func appendListHeader(buf []byte, payload int) []byte {
+	if payload < 56 {
+		return append(buf, 0xc0+byte(payload))
+	}
+	return appendLongHeader(buf, 0xf7, payload)
+}
+
+// listEncodedLen returns the total RLP-encoded length - header plus payload -
+// of a list whose payload is payload bytes long.
+func listEncodedLen(payload int) int {
+	if payload < 56 {
+		return 1 + payload
+	}
+	return 1 + intsize(payload) + payload
+}
+
+// appendLongHeader appends the long-form RLP header (used once a string or
+// list payload reaches 56 bytes) for a payload of length n, with base as the
+// 0xb7/0xf7 prefix for strings/lists respectively.
+func appendLongHeader(buf []byte, base byte, n int) []byte {
+	var lenBytes [8]byte
+	i := 8
+	for n > 0 {
+		i--
+		lenBytes[i] = byte(n)
+		n >>= 8
+	}
+	buf = append(buf, base+byte(8-i))
+	return append(buf, lenBytes[i:]...)
+}
+
+// intsize returns the minimal number of bytes needed to hold n.
+func intsize(n int) int {
+	size := 1
+	for n >= 256 {
+		size++
+		n >>= 8
+	}
+	return size
+}
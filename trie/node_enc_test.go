@@ -0,0 +1,117 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// nilValueNode stands in for an absent fullNode child slot in the form an
+// empty valueNode takes. The fullNode shapes below also leave bare nil
+// slots; encodeNode treats the two identically (both emit the empty string
+// 0x80).
+var nilValueNode = valueNode(nil)
+
+// FuzzEncodeNode checks that encodeNode produces byte-for-byte the same
+// wire format as the reflection-based rlp.Encode, across shortNode and
+// fullNode shapes, including embedded (<32-byte) children and the 17-slot
+// value case - this is exactly the shape of node that a small embedded leaf
+// produces, which a prior version of appendChild/childEncodedLen paniced on.
+func FuzzEncodeNode(f *testing.F) {
+	f.Add(uint8(0), []byte{0x3f}, []byte("hi"))
+	f.Add(uint8(1), []byte{0x20, 0x0a, 0xbc}, bytes.Repeat([]byte{0xcd}, 32))
+	f.Add(uint8(2), []byte{0x01, 0x02, 0x03, 0x04}, []byte("short-value"))
+	f.Add(uint8(3), []byte{}, []byte("seventeenth-slot-value"))
+
+	f.Fuzz(func(t *testing.T, shape uint8, keyBytes, valBytes []byte) {
+		n := buildFuzzNode(shape, keyBytes, valBytes)
+
+		want, err := rlp.EncodeToBytes(n)
+		if err != nil {
+			t.Fatalf("rlp.EncodeToBytes(%T) failed: %v", n, err)
+		}
+		got := nodeToBytes(n)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("encodeNode mismatch for %T\n got:  %x\n want: %x", n, got, want)
+		}
+	})
+}
+
+// buildFuzzNode turns the fuzzer-supplied bytes into one of a handful of
+// representative node shapes. keyBytes/valBytes are reused across slots
+// where more than one value is needed; their exact content doesn't matter,
+// only that both short (embeddable) and 32-byte (hash-sized) lengths occur.
+func buildFuzzNode(shape uint8, keyBytes, valBytes []byte) node {
+	embedded := valueNode(truncate(valBytes, 31))
+	hashSized := hashNode(fit32(valBytes))
+
+	switch shape % 4 {
+	case 0:
+		// shortNode with an embedded value child.
+		return &shortNode{Key: keyBytes, Val: embedded}
+	case 1:
+		// shortNode with a hash-sized value child.
+		return &shortNode{Key: keyBytes, Val: hashSized}
+	case 2:
+		// fullNode mixing nil, embedded and hash-sized children, plus an
+		// embedded shortNode child.
+		var full fullNode
+		for i := 0; i < 16; i++ {
+			switch (i + int(shape)) % 3 {
+			case 0:
+				full.Children[i] = nilValueNode
+			case 1:
+				full.Children[i] = embedded
+			case 2:
+				full.Children[i] = hashSized
+			}
+		}
+		// An embedded (<32-byte) shortNode child, the shape a small leaf
+		// takes when it is stored inline in its parent.
+		full.Children[0] = &shortNode{Key: truncate(keyBytes, 4), Val: valueNode(truncate(valBytes, 8))}
+		return &full
+	default:
+		// fullNode using its 17th (value) slot.
+		var full fullNode
+		full.Children[16] = embedded
+		return &full
+	}
+}
+
+// truncate returns b capped to at most n bytes, falling back to a
+// deterministic non-empty slice when b is empty so the embedded-value case
+// is always exercised.
+func truncate(b []byte, n int) []byte {
+	if len(b) == 0 {
+		b = []byte{0x01}
+	}
+	if len(b) > n {
+		b = b[:n]
+	}
+	return b
+}
+
+// fit32 pads or truncates b to exactly 32 bytes, for building a realistic
+// hash-sized child regardless of what the fuzzer happened to supply.
+func fit32(b []byte) []byte {
+	out := make([]byte, 32)
+	copy(out, b)
+	return out
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProofSink is notified of every node a committer stores, keyed by the
+// node's hex path. Because commit already visits every dirty node with its
+// full hex path, a ProofSink lets a caller accumulate a proof or witness in
+// the same pass, instead of paying the O(N·log N) cost of a separate
+// trie.Prove walk per key.
+type ProofSink interface {
+	// Put is called once for each dirty node as it is stored, with its hex
+	// path, hash and RLP encoding. enc is the same slice the committer adds
+	// to its nodeset, so implementations must not modify it.
+	Put(path []byte, hash common.Hash, enc []byte)
+}
+
+// WitnessBuilder is a ProofSink that records only the nodes lying on the
+// path to one of a fixed set of target keys, producing a witness suitable
+// for stateless verification of those keys.
+type WitnessBuilder struct {
+	prefixes [][]byte // hex-encoded target keys
+	nodes    map[common.Hash][]byte
+}
+
+// NewWitnessBuilder returns a ProofSink that collects, for the given set of
+// keys, every node whose hex path is a prefix of one of them - i.e. exactly
+// the nodes needed to prove each key - without a separate Prove walk per
+// key. Typical use is building a block witness for stateless clients.
+func NewWitnessBuilder(keys [][]byte) ProofSink {
+	prefixes := make([][]byte, len(keys))
+	for i, key := range keys {
+		prefixes[i] = keybytesToHex(key)
+	}
+	return &WitnessBuilder{
+		prefixes: prefixes,
+		nodes:    make(map[common.Hash][]byte),
+	}
+}
+
+// Put implements ProofSink.
+func (w *WitnessBuilder) Put(path []byte, hash common.Hash, enc []byte) {
+	for _, prefix := range w.prefixes {
+		if bytes.HasPrefix(prefix, path) {
+			w.nodes[hash] = common.CopyBytes(enc)
+			return
+		}
+	}
+}
+
+// Witness returns the accumulated proof nodes, keyed by node hash.
+func (w *WitnessBuilder) Witness() map[common.Hash][]byte {
+	return w.nodes
+}
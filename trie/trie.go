@@ -0,0 +1,92 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/trie/triestate"
+)
+
+// emptyRoot is the known root hash of an empty trie, i.e. common.Hash(keccak256(rlp(""))).
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// Trie is a Merkle Patricia Trie.
+type Trie struct {
+	root   node
+	owner  common.Hash
+	tracer *tracer
+
+	// parallelCommit enables commitChildren's bounded-worker-pool fan-out
+	// for this trie's future Commit calls. It's off by default; callers
+	// that commit many large tries back-to-back (state commit, snap sync)
+	// opt in with SetParallelCommit for the tries where the fan-out pays
+	// for itself - see commitChildrenParallel.
+	parallelCommit bool
+}
+
+// SetParallelCommit enables or disables the parallel commit fan-out for this
+// trie's future Commit calls.
+func (t *Trie) SetParallelCommit(parallel bool) {
+	t.parallelCommit = parallel
+}
+
+// Commit collapses the trie into a hash node and returns the root hash
+// together with the set of dirty nodes that must be flushed to the
+// database. It commits through the parallel fan-out when the trie has been
+// configured for it via SetParallelCommit.
+func (t *Trie) Commit(collectLeaf bool) (common.Hash, *trienode.NodeSet) {
+	nodes := trienode.NewNodeSet(t.owner)
+	if t.root == nil {
+		return emptyRoot, nodes
+	}
+	if t.tracer == nil {
+		t.tracer = newTracer()
+	}
+	h := newCommitter(nodes, t.tracer, t.parallelCommit, nil)
+	defer returnCommitterToPool(h)
+
+	newRoot := h.Commit(t.root)
+	t.root = newRoot
+	return common.BytesToHash(newRoot), nodes
+}
+
+// CommitWithLeafCallback is like Commit, but additionally streams every
+// committed leaf through cb via a pool of workers (see
+// committer.CommitWithLeafCallback), so a caller such as core/state can
+// populate its account-storage-root map concurrently with hashing the
+// trie instead of re-walking it afterwards. It commits through the
+// parallel fan-out when the trie has been configured for it via
+// SetParallelCommit.
+func (t *Trie) CommitWithLeafCallback(cb triestate.LeafCallback, workers int) (common.Hash, *trienode.NodeSet, error) {
+	nodes := trienode.NewNodeSet(t.owner)
+	if t.root == nil {
+		return emptyRoot, nodes, nil
+	}
+	if t.tracer == nil {
+		t.tracer = newTracer()
+	}
+	h := newCommitter(nodes, t.tracer, t.parallelCommit, nil)
+	defer returnCommitterToPool(h)
+
+	newRoot, err := h.CommitWithLeafCallback(t.root, cb, workers)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	t.root = newRoot
+	return common.BytesToHash(newRoot), nodes, nil
+}